@@ -0,0 +1,136 @@
+// Command gogorobot crawls a list of domains (one per line on stdin),
+// fetches each domain's robots.txt politely, and persists the results
+// through a pluggable sink. See pkg/fetcher for the crawling logic itself;
+// this is just the flags and wiring.
+package main
+
+// Special notes re: DNS resolution
+// https://code.google.com/p/go/issues/detail?id=3575
+// https://groups.google.com/forum/#!topic/golang-nuts/pP3zyUlbT00
+// http://grokbase.com/t/gg/golang-nuts/142vch7a3t/go-nuts-tcp-dial-dns-lookup-errors
+// https://groups.google.com/forum/#!topic/golang-nuts/wliZf2_LUag
+// https://code.google.com/p/go/issues/detail?id=8434
+// nasa.gov & navy.mil fail as they require www
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/Smerity/gogorobot/pkg/fetcher"
+	"github.com/op/go-logging"
+)
+
+var log = logging.MustGetLogger("gogorobot")
+
+var (
+	sinkName   = flag.String("sink", "sqlite", "storage backend: sqlite, sql, json, or warc")
+	sinkDriver = flag.String("sink-driver", "", "database/sql driver name, used when -sink=sql (e.g. postgres, mysql)")
+	sinkPath   = flag.String("sink-path", "./robots.db", "sink destination: a file path (sqlite/json/warc) or DSN (sql); \"-\" means stdout for json")
+
+	maxPerHost   = flag.Int("max-per-host", 64, "number of per-host queues to hash domains into; each queue releases one request at a time")
+	defaultDelay = flag.Duration("default-delay", 5*time.Second, "minimum delay between requests to the same host, absent a Crawl-delay override")
+	globalQPS    = flag.Int("global-qps", 0, "cap on total requests per second across all hosts; 0 means unlimited")
+
+	insecureSkipVerify = flag.Bool("insecure-skip-verify", false, "skip TLS certificate verification on HTTPS fetches, for research crawls across misconfigured CAs")
+	rootCAPath         = flag.String("root-ca-bundle", "", "path to a PEM file of additional root CAs to trust for HTTPS fetches")
+
+	refresh = flag.Bool("refresh", false, "read known domains from the sink instead of stdin, and make conditional requests against them")
+	workers = flag.Int("workers", 50, "number of concurrent fetch workers")
+)
+
+func main() {
+	flag.Parse()
+	logging.SetFormatter(logging.MustStringFormatter(fetcher.DefaultLogFormat))
+	logging.SetLevel(logging.INFO, "gogorobot")
+	//logging.SetLevel(logging.DEBUG, "gogorobot")
+
+	// Set the file descriptor limit higher if we've permission
+	var rLimit syscall.Rlimit
+	err := syscall.Getrlimit(syscall.RLIMIT_NOFILE, &rLimit)
+	if err != nil {
+		log.Info(fmt.Sprintf("Error geting rlimit: %s", err))
+	}
+	rLimit.Max = 65536
+	rLimit.Cur = 65536
+	err = syscall.Setrlimit(syscall.RLIMIT_NOFILE, &rLimit)
+	if err != nil {
+		log.Info(fmt.Sprintf("Error setting rlimit: %s", err))
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	sink, err := fetcher.NewSink(*sinkName, *sinkDriver, *sinkPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	scheduler := fetcher.NewScheduler(*maxPerHost, *defaultDelay, *globalQPS)
+	f := fetcher.New(*workers, scheduler, sink)
+	f.InsecureSkipVerify = *insecureSkipVerify
+	f.RootCAPath = *rootCAPath
+
+	fetchPipeline := make(chan fetcher.FetchRequest)
+	var schedGroup sync.WaitGroup
+	schedGroup.Add(1)
+	go func() {
+		defer schedGroup.Done()
+		scheduler.Run(fetchPipeline)
+	}()
+
+	// f.Run must be draining fetchPipeline concurrently with the scheduler
+	// feeding it: fetchPipeline is unbuffered, so if nothing reads it until
+	// after schedGroup.Wait() returns below, the scheduler's first send
+	// blocks forever and schedGroup.Wait() never returns.
+	runDone := make(chan struct{})
+	go func() {
+		defer close(runDone)
+		f.Run(ctx, fetchPipeline)
+	}()
+
+	if *refresh {
+		source, ok := sink.(fetcher.Source)
+		if !ok {
+			log.Fatal(fmt.Sprintf("-refresh requires a sink that supports reading back domains; -sink=%s does not", *sinkName))
+		}
+		entries, err := source.ReadDomains()
+		if err != nil {
+			log.Fatal(err)
+		}
+		f.Cache.Seed(entries)
+		for _, entry := range entries {
+			log.Debug(fmt.Sprintf("MAIN: Providing %s to scheduler for refresh", entry.Domain))
+			scheduler.Submit(fetcher.FetchRequest{Domain: entry.Domain})
+		}
+	} else {
+		reader := bufio.NewReader(os.Stdin)
+		for {
+			domain, err := reader.ReadString('\n')
+			if err != nil {
+				break
+			}
+			domain = strings.TrimRight(domain, "\r\n")
+			log.Debug(fmt.Sprintf("MAIN: Providing %s to scheduler", domain))
+			scheduler.Submit(fetcher.FetchRequest{Domain: domain})
+		}
+	}
+
+	scheduler.Close()
+	schedGroup.Wait()
+	close(fetchPipeline)
+	log.Notice("Fetching pipeline closed -- waiting for pending fetches to complete")
+
+	// runDone closes once f.Run has drained fetchPipeline and every fetch
+	// has been saved, so there's no need for the fixed time.Sleep the
+	// single-file version used to rely on; ctx lets an interrupt cut a
+	// long-running crawl short instead.
+	<-runDone
+}
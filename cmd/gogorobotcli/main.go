@@ -0,0 +1,31 @@
+// Based upon code by jgc (github.com/jgrahamc/dotgo) -- MIT
+// https://github.com/cloudflare/jgc-talks/blob/master/dotGo/2014/EasyConcurrencyEasyComposition.pdf
+
+// Command gogorobotcli reads domains from stdin and prints one line per
+// domain to stdout: the domain, whether it has a robots.txt, and the
+// RobotResponse as JSON, tab-separated, via the task/factory worker pool in
+// pkg/fetcher.
+package main
+
+import (
+	"context"
+	"flag"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/Smerity/gogorobot/pkg/fetcher"
+)
+
+var workers = flag.Int("workers", 500, "number of concurrent fetch workers")
+
+func main() {
+	flag.Parse()
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	f := fetcher.New(*workers, nil, nil)
+	factory := &fetcher.RobotsFactory{Fetcher: f}
+	fetcher.RunTasks(ctx, os.Stdin, factory, *workers)
+}
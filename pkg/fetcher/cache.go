@@ -0,0 +1,50 @@
+package fetcher
+
+import "sync"
+
+// CachedEntry is what we remember about the last successful fetch of a
+// domain so a later pass can make a conditional request instead of a full
+// refetch.
+type CachedEntry struct {
+	Domain       string
+	ETag         string
+	LastModified string
+	Body         []byte
+	HasRobots    bool
+}
+
+// ConditionalCache tracks the ETag / Last-Modified seen for each domain so
+// Fetcher.Fetch can send If-None-Match / If-Modified-Since on the next fetch
+// and treat a 304 as "unchanged" rather than paying for a full body
+// transfer.
+type ConditionalCache struct {
+	mu      sync.Mutex
+	entries map[string]CachedEntry
+}
+
+func NewConditionalCache() *ConditionalCache {
+	return &ConditionalCache{entries: make(map[string]CachedEntry)}
+}
+
+func (c *ConditionalCache) Get(domain string) (CachedEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[domain]
+	return e, ok
+}
+
+func (c *ConditionalCache) Set(e CachedEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[e.Domain] = e
+}
+
+// Seed bulk-loads entries, e.g. the domains read back from the sink when
+// -refresh is passed.
+func (c *ConditionalCache) Seed(entries []CachedEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, e := range entries {
+		c.entries[e.Domain] = e
+	}
+}
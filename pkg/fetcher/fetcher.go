@@ -0,0 +1,450 @@
+// Package fetcher holds the crawling logic shared by gogorobot's two
+// command-line front ends: the pipeline/SQLite-oriented cmd/gogorobot and
+// the task/factory-oriented cmd/gogorobotcli. Extracting it here means the
+// fetch/politeness/storage machinery can be used as a dependency, and a
+// caller gets a context.Context-driven shutdown instead of the fixed
+// time.Sleep a single binary used to rely on.
+package fetcher
+
+import (
+	"context"
+	_ "crypto/sha512" // See http://bridge.grumpy-troll.org/2014/05/golang-tls-comodo/
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	httpclient "github.com/mreiferson/go-httpclient"
+	"github.com/op/go-logging"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+var log = logging.MustGetLogger("gogorobot")
+
+// DefaultLogFormat is the go-logging format string cmd/gogorobot has used
+// since the original single-file version.
+const DefaultLogFormat = "%{color}%{time:15:04:05.000000} â–¶ %{level:.4s} %{id:03x}%{color:reset} %{shortfile} %{message}"
+
+type FetchRequest struct {
+	Domain  string
+	Attempt uint
+}
+
+type RobotResponse struct {
+	Domain    string
+	Url       string
+	HasRobots bool
+	FetchTime time.Time
+	Body      []byte
+	Redirects int
+	// Scheme records which protocol the fetch actually succeeded over,
+	// "https" or "http", after the HTTPS-first / downgrade logic in Fetch
+	// runs.
+	Scheme string
+	// TLSError classifies a TLS failure encountered along the way (e.g.
+	// "unknown_authority", "hostname_mismatch", "handshake_timeout"), even
+	// if a subsequent plain-HTTP fetch succeeded. Empty means no TLS error
+	// was seen.
+	TLSError string
+	// ETag and LastModified carry forward the response headers so the next
+	// fetch of this domain can go conditional via If-None-Match /
+	// If-Modified-Since.
+	ETag         string
+	LastModified string
+	// Unchanged is true when this response came from a 304: the body and
+	// status are identical to what's already in the sink, so saveLoop
+	// skips writing a duplicate row for it.
+	Unchanged bool
+	// ContentType is the response's declared Content-Type, recorded as-is
+	// even when it isn't text/plain (e.g. sites that serve robots.txt as
+	// text/html) since the body is still parsed regardless.
+	ContentType string
+}
+
+// Fetcher owns everything needed to turn a domain into a RobotResponse:
+// the shared HTTP transport, TLS options, worker count, where responses
+// are persisted, and the scheduler that paces per-host requests.
+type Fetcher struct {
+	Workers            int
+	InsecureSkipVerify bool
+	RootCAPath         string
+	Scheduler          *Scheduler
+	Sink               Sink
+	Cache              *ConditionalCache
+
+	transportOnce sync.Once
+	transport     *httpclient.Transport
+}
+
+// New builds a Fetcher. scheduler and sink may be nil if the caller only
+// intends to use Fetch directly rather than Run.
+func New(workers int, scheduler *Scheduler, sink Sink) *Fetcher {
+	return &Fetcher{
+		Workers:   workers,
+		Scheduler: scheduler,
+		Sink:      sink,
+		Cache:     NewConditionalCache(),
+	}
+}
+
+func (f *Fetcher) getTransport() *httpclient.Transport {
+	f.transportOnce.Do(func() {
+		f.transport = &httpclient.Transport{
+			// Prime times are useful as one can see when there's an obvious bottleneck
+			ConnectTimeout:        7 * time.Second,
+			RequestTimeout:        9 * time.Second,
+			ResponseHeaderTimeout: 11 * time.Second,
+			// After we use the connection once, we won't be using it again as robots.txt is all we want
+			DisableKeepAlives: true,
+			// In Go 1.2.1, short gzip body responses can result in failures and leaking connections
+			// See https://codereview.appspot.com/84850043 for more details
+			DisableCompression: true,
+		}
+		if f.InsecureSkipVerify || f.RootCAPath != "" {
+			f.transport.TLSClientConfig = f.buildTLSConfig()
+		}
+	})
+	return f.transport
+}
+
+// Fetch resolves a single domain to a RobotResponse: it tries HTTPS first,
+// falls back to HTTP on a TLS or connection failure, retries on DNS/www
+// and transient network errors up to two additional attempts, and honors
+// any cached ETag/Last-Modified via a conditional request.
+func (f *Fetcher) Fetch(ctx context.Context, domain string) (*RobotResponse, error) {
+	var lastVia []*http.Request
+	client := &http.Client{
+		Transport: f.getTransport(),
+		CheckRedirect: func(_ *http.Request, via []*http.Request) error {
+			lastVia = via
+			if len(via) > 10 {
+				return errors.New("stopped after 10 redirects")
+			}
+			return nil
+		},
+	}
+	return f.fetchAttempt(ctx, client, &lastVia, domain, 0)
+}
+
+func (f *Fetcher) fetchAttempt(ctx context.Context, client *http.Client, lastVia *[]*http.Request, domain string, attempt int) (*RobotResponse, error) {
+	if attempt > 2 {
+		return nil, fmt.Errorf("maximum attempts reached for %s", domain)
+	}
+	log.Debug(fmt.Sprintf("FTCH: Fetching %s on attempt %d", domain, attempt+1))
+
+	*lastVia = nil
+	// RFC 9309 / current practice: try HTTPS first, and only fall back to
+	// plain HTTP if the connection is refused or the TLS handshake itself
+	// fails.
+	scheme := "https"
+	req, err := http.NewRequest("GET", scheme+"://"+domain+"/robots.txt", nil)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	applyConditionalHeaders(req, domain, f.Cache)
+	resp, fetchErr := client.Do(req)
+	tlsError := classifyTLSError(fetchErr)
+	if fetchErr != nil && isHTTPSFallbackError(fetchErr) {
+		log.Warning(fmt.Sprintf("TLS: %s: %s, falling back to http", domain, fetchErr))
+		scheme = "http"
+		req, err = http.NewRequest("GET", scheme+"://"+domain+"/robots.txt", nil)
+		if err != nil {
+			return nil, err
+		}
+		req = req.WithContext(ctx)
+		applyConditionalHeaders(req, domain, f.Cache)
+		resp, fetchErr = client.Do(req)
+	}
+	if fetchErr != nil {
+		if urlErr, ok := fetchErr.(*url.Error); ok {
+			if netErr, ok := (urlErr.Err).(*net.OpError); ok {
+				if _, ok := (netErr.Err).(*net.DNSError); ok && !strings.HasPrefix(domain, "www.") {
+					log.Warning(fmt.Sprintf("DNS error: %s: trying with an added www", domain))
+					return f.fetchAttempt(ctx, client, lastVia, "www."+domain, attempt+1)
+				}
+				if netErr.Timeout() || netErr.Temporary() {
+					log.Warning(fmt.Sprintf("Restarting request: %s: timeout / temporary issue... %v", domain, netErr))
+					return f.fetchAttempt(ctx, client, lastVia, domain, attempt+1)
+				}
+			}
+		}
+		// Otherwise, report as domain with no URL -- implies extreme badness
+		log.Warning(fmt.Sprintf("%s", fetchErr))
+		return &RobotResponse{Domain: domain, FetchTime: time.Now(), TLSError: tlsError}, nil
+	}
+	defer resp.Body.Close()
+
+	finalUrl := resp.Request.URL.String()
+	redirects := len(*lastVia)
+
+	// A 304 means "unchanged": refresh fetchTime and reuse the cached body
+	// rather than treating it as a failed fetch.
+	if resp.StatusCode == http.StatusNotModified {
+		entry, _ := f.Cache.Get(domain)
+		log.Debug(fmt.Sprintf("FTCH: %s unchanged (304)", domain))
+		f.applyCrawlDelay(domain, entry.Body)
+		return &RobotResponse{
+			Domain: domain, Url: finalUrl, HasRobots: entry.HasRobots,
+			FetchTime: time.Now(), Body: entry.Body, Redirects: redirects,
+			Scheme: scheme, TLSError: tlsError,
+			ETag: entry.ETag, LastModified: entry.LastModified,
+			Unchanged: true,
+		}, nil
+	}
+
+	// RFC[3.1] states 2xx should be considered success
+	if resp.StatusCode < 200 || resp.StatusCode > 206 {
+		return &RobotResponse{Domain: domain, Url: finalUrl, FetchTime: time.Now(), Redirects: redirects, Scheme: scheme, TLSError: tlsError}, nil
+	}
+
+	// RFC[3.1] states robots.txt should be text/plain, but plenty of real
+	// sites (e.g. http://www.weibo.com/robots.txt) serve it as text/html.
+	// Rather than drop those bodies before the parser ever sees them, read
+	// and parse any 2xx body regardless of declared type, and just record
+	// the real Content-Type alongside it.
+	contentType := strings.Join(resp.Header["Content-Type"], ", ")
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		log.Warning(fmt.Sprintf("%s", err))
+		return f.fetchAttempt(ctx, client, lastVia, domain, attempt+1)
+	}
+
+	etag := resp.Header.Get("ETag")
+	lastModified := resp.Header.Get("Last-Modified")
+	f.Cache.Set(CachedEntry{Domain: domain, ETag: etag, LastModified: lastModified, Body: body, HasRobots: true})
+	f.applyCrawlDelay(domain, body)
+	return &RobotResponse{
+		Domain: domain, Url: finalUrl, HasRobots: true, FetchTime: time.Now(), Body: body,
+		Redirects: redirects, Scheme: scheme, TLSError: tlsError,
+		ETag: etag, LastModified: lastModified, ContentType: contentType,
+	}, nil
+}
+
+// applyCrawlDelay feeds a successfully-fetched robots.txt's own Crawl-delay
+// back into f.Scheduler, so a subsequent refetch of domain honors it instead
+// of the scheduler's default delay. f.Scheduler is nil for callers (like
+// cmd/gogorobotcli) that only use Fetch directly without the scheduler, and
+// the "*" group is used since this crawler doesn't identify itself under a
+// specific user-agent.
+func (f *Fetcher) applyCrawlDelay(domain string, body []byte) {
+	if f.Scheduler == nil || len(body) == 0 {
+		return
+	}
+	if delay, ok := ParseRobots(body).CrawlDelay("*"); ok {
+		f.Scheduler.SetCrawlDelay(domain, delay)
+	}
+}
+
+// applyConditionalHeaders attaches If-None-Match / If-Modified-Since from
+// the cache entry for domain, if one exists, so an unchanged robots.txt
+// costs a 304 instead of a full body transfer.
+func applyConditionalHeaders(req *http.Request, domain string, cache *ConditionalCache) {
+	entry, ok := cache.Get(domain)
+	if !ok {
+		return
+	}
+	if entry.ETag != "" {
+		req.Header.Set("If-None-Match", entry.ETag)
+	}
+	if entry.LastModified != "" {
+		req.Header.Set("If-Modified-Since", entry.LastModified)
+	}
+}
+
+// isHTTPSFallbackError reports whether err is the kind of failure that
+// justifies retrying over plain HTTP: the TLS handshake itself failed, or
+// the connection was refused outright (a strong signal the host has no
+// listener on 443 at all).
+func isHTTPSFallbackError(err error) bool {
+	if err == nil {
+		return false
+	}
+	if _, ok := err.(tls.RecordHeaderError); ok {
+		return true
+	}
+	urlErr, ok := err.(*url.Error)
+	if !ok {
+		return false
+	}
+	// errors.As rather than a type switch: newer Go releases wrap x509
+	// failures in a *tls.CertificateVerificationError.
+	var unknownAuthority x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var invalidErr x509.CertificateInvalidError
+	var recordErr tls.RecordHeaderError
+	if errors.As(urlErr.Err, &unknownAuthority) || errors.As(urlErr.Err, &hostnameErr) ||
+		errors.As(urlErr.Err, &invalidErr) || errors.As(urlErr.Err, &recordErr) {
+		return true
+	}
+	if netErr, ok := urlErr.Err.(*net.OpError); ok {
+		if sysErr, ok := netErr.Err.(*os.SyscallError); ok {
+			return sysErr.Err == syscall.ECONNREFUSED
+		}
+	}
+	return false
+}
+
+// classifyTLSError turns the TLS-specific failures surfaced by net/http
+// into the stable strings persisted in the tlsError column, instead of
+// lumping them in with generic network failures. Returns "" when err is
+// nil or not TLS-related.
+func classifyTLSError(err error) string {
+	if err == nil {
+		return ""
+	}
+	urlErr, ok := err.(*url.Error)
+	if !ok {
+		return ""
+	}
+	var unknownAuthority x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var invalidErr x509.CertificateInvalidError
+	// errors.As rather than matching the x509 types directly: newer Go
+	// releases wrap them in a *tls.CertificateVerificationError.
+	switch {
+	case errors.As(urlErr.Err, &unknownAuthority):
+		return "unknown_authority"
+	case errors.As(urlErr.Err, &hostnameErr):
+		return "hostname_mismatch"
+	case errors.As(urlErr.Err, &invalidErr):
+		return "certificate_invalid"
+	}
+	if e, ok := urlErr.Err.(net.Error); ok && e.Timeout() {
+		// A *net.OpError with Op=="dial" is a plain TCP connect
+		// timeout -- it never got far enough to attempt a TLS
+		// handshake, so it belongs with the generic network
+		// failures this function deliberately excludes.
+		if opErr, ok := urlErr.Err.(*net.OpError); ok && opErr.Op == "dial" {
+			return ""
+		}
+		return "handshake_timeout"
+	}
+	return ""
+}
+
+// buildTLSConfig applies InsecureSkipVerify and RootCAPath to the
+// transport used for HTTPS fetches, for research crawls that need to reach
+// sites behind misconfigured intermediate CAs.
+func (f *Fetcher) buildTLSConfig() *tls.Config {
+	cfg := &tls.Config{InsecureSkipVerify: f.InsecureSkipVerify}
+	if f.RootCAPath != "" {
+		pem, err := ioutil.ReadFile(f.RootCAPath)
+		if err != nil {
+			log.Fatal(fmt.Sprintf("Unable to read root CA bundle %s: %s", f.RootCAPath, err))
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			log.Fatal(fmt.Sprintf("No certificates found in root CA bundle %s", f.RootCAPath))
+		}
+		cfg.RootCAs = pool
+	}
+	return cfg
+}
+
+// Run drives the pipeline end of the library: it starts Workers goroutines
+// pulling FetchRequests off requests, fetches each, and hands the result to
+// Sink, flushing once a second until requests is closed and drained. It
+// returns once every in-flight fetch has been saved, so callers shut down
+// by closing requests (or canceling ctx) rather than sleeping a fixed
+// duration and hoping the pipeline is empty.
+func (f *Fetcher) Run(ctx context.Context, requests <-chan FetchRequest) {
+	results := make(chan RobotResponse)
+
+	var workerGroup sync.WaitGroup
+	for i := 0; i < f.Workers; i++ {
+		workerGroup.Add(1)
+		go func() {
+			defer workerGroup.Done()
+			for {
+				var fr FetchRequest
+				select {
+				case next, open := <-requests:
+					if !open {
+						return
+					}
+					fr = next
+				case <-ctx.Done():
+					return
+				}
+				resp, err := f.Fetch(ctx, fr.Domain)
+				if err != nil {
+					log.Error(fmt.Sprintf("FTCH: %s", err))
+					continue
+				}
+				select {
+				case results <- *resp:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+
+	saveDone := make(chan struct{})
+	go func() {
+		defer close(saveDone)
+		f.saveLoop(results)
+	}()
+
+	workerGroup.Wait()
+	close(results)
+	<-saveDone
+}
+
+// saveLoop persists everything it reads from results into Sink, flushing
+// once a second, until results is closed.
+func (f *Fetcher) saveLoop(results <-chan RobotResponse) {
+	delay := 1 * time.Second
+	tick := time.Tick(delay)
+	saveCount := 0
+	failCount := 0
+	unchangedCount := 0
+
+Loop:
+	for {
+		select {
+		case resp, open := <-results:
+			if !open {
+				break Loop
+			}
+			if resp.Unchanged {
+				// A 304 means the sink already has this body/status on
+				// record; writing it again would just bloat the table on
+				// every -refresh rerun for no new information.
+				log.Debug(fmt.Sprintf("SV: Skipping unchanged %s", resp.Domain))
+				unchangedCount += 1
+				continue
+			}
+			log.Debug(fmt.Sprintf("SV: Saving %s", resp.Domain))
+			if err := f.Sink.Write(resp); err != nil {
+				log.Fatal(err)
+			}
+			if resp.Url == "" {
+				failCount += 1
+			}
+			saveCount += 1
+		case <-tick:
+			log.Notice("Saving... %d in %s\n", saveCount, delay)
+			log.Notice("Failing... %d in %s\n", failCount, delay)
+			log.Notice("Unchanged... %d in %s\n", unchangedCount, delay)
+			saveCount = 0
+			failCount = 0
+			unchangedCount = 0
+			if err := f.Sink.Flush(); err != nil {
+				log.Fatal(err)
+			}
+		}
+	}
+	if err := f.Sink.Close(); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,209 @@
+package fetcher
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Rule is a single Allow/Disallow line scoped to one user-agent group.
+type Rule struct {
+	Allow bool
+	Path  string
+}
+
+// ParsedRobots is the normalized form of a robots.txt body: rules grouped
+// by (lowercased) user-agent, any Crawl-delay per group, and every Sitemap
+// URL declared anywhere in the file.
+type ParsedRobots struct {
+	// UserAgents lists every user-agent seen, in file order, lowercased.
+	UserAgents []string
+	Sitemaps   []string
+
+	rules      map[string][]Rule
+	crawlDelay map[string]time.Duration
+}
+
+// ParseRobots decodes a robots.txt body per RFC 9309: CRLF and bare-CR line
+// endings, a leading BOM, and comments starting mid-line are all tolerated.
+// Lines that aren't "field: value" are skipped rather than treated as
+// fatal, since real-world robots.txt files are rarely strictly conformant --
+// including when they're served with a Content-Type other than text/plain,
+// which fetchAttempt no longer filters on before handing the body here.
+func ParseRobots(body []byte) *ParsedRobots {
+	text := strings.TrimPrefix(string(body), "\ufeff")
+	text = strings.NewReplacer("\r\n", "\n", "\r", "\n").Replace(text)
+
+	p := &ParsedRobots{
+		rules:      make(map[string][]Rule),
+		crawlDelay: make(map[string]time.Duration),
+	}
+
+	var currentAgents []string
+	groupOpen := false // true once the current agent block has taken a rule
+
+	for _, line := range strings.Split(text, "\n") {
+		if idx := strings.IndexByte(line, '#'); idx >= 0 {
+			line = line[:idx]
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		colon := strings.IndexByte(line, ':')
+		if colon < 0 {
+			continue
+		}
+		field := strings.ToLower(strings.TrimSpace(line[:colon]))
+		value := strings.TrimSpace(line[colon+1:])
+
+		switch field {
+		case "user-agent":
+			ua := strings.ToLower(value)
+			if ua == "" {
+				continue
+			}
+			// A user-agent line after the group has already taken a rule
+			// starts a new group; consecutive user-agent lines extend the
+			// same group instead.
+			if groupOpen {
+				currentAgents = nil
+				groupOpen = false
+			}
+			currentAgents = append(currentAgents, ua)
+			if !containsString(p.UserAgents, ua) {
+				p.UserAgents = append(p.UserAgents, ua)
+			}
+		case "allow", "disallow":
+			if len(currentAgents) == 0 {
+				continue
+			}
+			groupOpen = true
+			if value == "" && field == "disallow" {
+				// An empty Disallow means "allow everything" -- no rule needed.
+				continue
+			}
+			rule := Rule{Allow: field == "allow", Path: value}
+			for _, ua := range currentAgents {
+				p.rules[ua] = append(p.rules[ua], rule)
+			}
+		case "crawl-delay":
+			if len(currentAgents) == 0 {
+				continue
+			}
+			groupOpen = true
+			secs, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			for _, ua := range currentAgents {
+				p.crawlDelay[ua] = time.Duration(secs * float64(time.Second))
+			}
+		case "sitemap":
+			if value != "" {
+				p.Sitemaps = append(p.Sitemaps, value)
+			}
+		}
+	}
+
+	return p
+}
+
+func containsString(haystack []string, needle string) bool {
+	for _, s := range haystack {
+		if s == needle {
+			return true
+		}
+	}
+	return false
+}
+
+// Rules returns the Allow/Disallow rules for a user-agent, falling back to
+// the "*" group. The second return value reports whether any group (named
+// or wildcard) matched at all.
+func (p *ParsedRobots) Rules(userAgent string) ([]Rule, bool) {
+	ua := strings.ToLower(userAgent)
+	if rules, ok := p.rules[ua]; ok {
+		return rules, true
+	}
+	if containsString(p.UserAgents, ua) {
+		// A named group with no Allow/Disallow rules still overrides "*".
+		return nil, true
+	}
+	rules, ok := p.rules["*"]
+	return rules, ok
+}
+
+// CrawlDelay returns the Crawl-delay declared for userAgent, falling back to
+// "*" only when userAgent has no group of its own. A named group that
+// simply didn't declare a Crawl-delay does not inherit the wildcard's, so
+// the caller can tell "no limit for this UA" apart from "UA wasn't
+// mentioned at all".
+func (p *ParsedRobots) CrawlDelay(userAgent string) (time.Duration, bool) {
+	ua := strings.ToLower(userAgent)
+	if d, ok := p.crawlDelay[ua]; ok {
+		return d, true
+	}
+	if containsString(p.UserAgents, ua) {
+		return 0, false
+	}
+	d, ok := p.crawlDelay["*"]
+	return d, ok
+}
+
+// Matches answers "would userAgent be allowed to fetch path", using the
+// longest-matching-rule-wins precedence RFC 9309 specifies, with ties
+// broken in favor of Allow. matchedRule is the path of the deciding rule,
+// or "" if no rule applied and the path is allowed by default.
+func (p *ParsedRobots) Matches(userAgent, path string) (allowed bool, matchedRule string) {
+	group, _ := p.Rules(userAgent)
+	allowed = true
+	bestLen := -1
+	for _, rule := range group {
+		if !pathMatches(path, rule.Path) {
+			continue
+		}
+		if len(rule.Path) > bestLen || (len(rule.Path) == bestLen && rule.Allow) {
+			bestLen = len(rule.Path)
+			allowed = rule.Allow
+			matchedRule = rule.Path
+		}
+	}
+	return allowed, matchedRule
+}
+
+// pathMatches implements the limited pattern language RFC 9309 §2.2.3
+// defines for Allow/Disallow paths: "*" matches any run of characters, and
+// a trailing "$" anchors the match to the end of path. Every other
+// character, including any other "$", is matched literally.
+func pathMatches(path, pattern string) bool {
+	anchored := strings.HasSuffix(pattern, "$")
+	if anchored {
+		pattern = pattern[:len(pattern)-1]
+	}
+
+	segments := strings.Split(pattern, "*")
+	if !strings.HasPrefix(path, segments[0]) {
+		return false
+	}
+	pos := len(segments[0])
+	for i, segment := range segments[1:] {
+		if anchored && i == len(segments)-2 {
+			// The last segment of an anchored pattern has to end exactly at
+			// the end of path, not at its first occurrence after pos.
+			return len(path)-pos >= len(segment) && strings.HasSuffix(path, segment)
+		}
+		if segment == "" {
+			continue
+		}
+		idx := strings.Index(path[pos:], segment)
+		if idx < 0 {
+			return false
+		}
+		pos += idx + len(segment)
+	}
+	if anchored {
+		return pos == len(path)
+	}
+	return true
+}
@@ -0,0 +1,133 @@
+package fetcher
+
+import "testing"
+
+func TestParseRobotsRules(t *testing.T) {
+	cases := []struct {
+		name string
+		body string
+		ua   string
+		path string
+
+		allowed     bool
+		matchedRule string
+	}{
+		{
+			name: "disallow wins over shorter allow",
+			body: "User-agent: *\nDisallow: /private\nAllow: /\n",
+			ua:   "Googlebot", path: "/private/page",
+			allowed: false, matchedRule: "/private",
+		},
+		{
+			name: "longer rule wins regardless of order",
+			body: "User-agent: *\nDisallow: /\nAllow: /public\n",
+			ua:   "Googlebot", path: "/public/page",
+			allowed: true, matchedRule: "/public",
+		},
+		{
+			name: "tie between allow and disallow favors allow",
+			body: "User-agent: *\nDisallow: /a\nAllow: /a\n",
+			ua:   "Googlebot", path: "/a",
+			allowed: true, matchedRule: "/a",
+		},
+		{
+			name: "named group overrides wildcard group",
+			body: "User-agent: *\nDisallow: /\nUser-agent: Googlebot\nAllow: /\n",
+			ua:   "Googlebot", path: "/anything",
+			allowed: true, matchedRule: "/",
+		},
+		{
+			name: "unmatched path defaults to allowed",
+			body: "User-agent: *\nDisallow: /private\n",
+			ua:   "Googlebot", path: "/public",
+			allowed: true, matchedRule: "",
+		},
+		{
+			name: "malformed lines without a colon are skipped",
+			body: "this is not a directive\nUser-agent: *\nDisallow: /private\n",
+			ua:   "Googlebot", path: "/private",
+			allowed: false, matchedRule: "/private",
+		},
+		{
+			name: "comments mid-line are stripped before parsing",
+			body: "User-agent: * # everyone\nDisallow: /private # keep out\n",
+			ua:   "Googlebot", path: "/private",
+			allowed: false, matchedRule: "/private",
+		},
+		{
+			name: "a repeated user-agent line starts a new group once rules were taken",
+			body: "User-agent: a\nDisallow: /a\nUser-agent: b\nDisallow: /b\n",
+			ua:   "a", path: "/b",
+			allowed: true, matchedRule: "",
+		},
+		{
+			name: "CRLF line endings are tolerated",
+			body: "User-agent: *\r\nDisallow: /private\r\n",
+			ua:   "Googlebot", path: "/private",
+			allowed: false, matchedRule: "/private",
+		},
+		{
+			name: "a leading BOM is stripped before parsing",
+			body: "\ufeffUser-agent: *\nDisallow: /private\n",
+			ua:   "Googlebot", path: "/private",
+			allowed: false, matchedRule: "/private",
+		},
+		{
+			name: "wildcard and end-anchor match a file extension",
+			body: "User-agent: *\nDisallow: /*.pdf$\n",
+			ua:   "Googlebot", path: "/secret.pdf",
+			allowed: false, matchedRule: "/*.pdf$",
+		},
+		{
+			name: "end-anchor does not match when path has a suffix",
+			body: "User-agent: *\nDisallow: /*.pdf$\n",
+			ua:   "Googlebot", path: "/secret.pdf.html",
+			allowed: true, matchedRule: "",
+		},
+		{
+			name: "bare wildcard mid-pattern matches any run of characters",
+			body: "User-agent: *\nDisallow: /private/*/secret\n",
+			ua:   "Googlebot", path: "/private/anything/secret",
+			allowed: false, matchedRule: "/private/*/secret",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			parsed := ParseRobots([]byte(c.body))
+			allowed, matchedRule := parsed.Matches(c.ua, c.path)
+			if allowed != c.allowed || matchedRule != c.matchedRule {
+				t.Errorf("Matches(%q, %q) = (%v, %q), want (%v, %q)",
+					c.ua, c.path, allowed, matchedRule, c.allowed, c.matchedRule)
+			}
+		})
+	}
+}
+
+func TestParseRobotsCrawlDelayAndSitemaps(t *testing.T) {
+	body := "User-agent: *\nCrawl-delay: 2.5\nSitemap: https://example.com/sitemap.xml\n" +
+		"User-agent: other\nDisallow: /x\n"
+	parsed := ParseRobots([]byte(body))
+
+	delay, ok := parsed.CrawlDelay("Googlebot")
+	if !ok || delay.Seconds() != 2.5 {
+		t.Errorf("CrawlDelay(Googlebot) = (%v, %v), want (2.5s, true)", delay, ok)
+	}
+
+	if _, ok := parsed.CrawlDelay("other"); ok {
+		t.Error("CrawlDelay(other) reported a delay, but only the wildcard group declared one")
+	}
+
+	if len(parsed.Sitemaps) != 1 || parsed.Sitemaps[0] != "https://example.com/sitemap.xml" {
+		t.Errorf("Sitemaps = %v, want [https://example.com/sitemap.xml]", parsed.Sitemaps)
+	}
+}
+
+func TestParseRobotsUnknownUserAgentFallsBackToWildcard(t *testing.T) {
+	parsed := ParseRobots([]byte("User-agent: *\nDisallow: /private\n"))
+
+	rules, ok := parsed.Rules("SomeOtherBot")
+	if !ok || len(rules) != 1 || rules[0].Path != "/private" {
+		t.Errorf("Rules(SomeOtherBot) = (%v, %v), want fallback to the wildcard group", rules, ok)
+	}
+}
@@ -0,0 +1,143 @@
+package fetcher
+
+import (
+	"sync"
+	"time"
+)
+
+// Scheduler sits between the domain reader and fetchPipeline. It hashes
+// each FetchRequest onto one of a fixed number of per-host queues, keyed on
+// the registered domain, and each queue releases its requests into
+// fetchPipeline one at a time from a single goroutine, spacing releases for
+// a host by at least its configured delay. The fetch itself runs on a
+// Fetcher worker after release, so two fetches of the same host can still
+// overlap when one takes longer than that host's delay. Without this, the
+// Fetcher's workers will happily slam the same domain concurrently.
+type Scheduler struct {
+	queues       []chan FetchRequest
+	defaultDelay time.Duration
+	globalQPS    int
+
+	mu        sync.Mutex
+	delays    map[string]time.Duration // per-host override, e.g. from Crawl-delay
+	lastFetch map[string]time.Time     // per-host, not per-queue: several hosts can share a queue
+}
+
+// NewScheduler creates a Scheduler with numQueues per-host queues. Each
+// queue is drained into fetchPipeline by its own goroutine so that host
+// ordering (and its delay) is serialized independent of the others.
+func NewScheduler(numQueues int, defaultDelay time.Duration, globalQPS int) *Scheduler {
+	s := &Scheduler{
+		queues:       make([]chan FetchRequest, numQueues),
+		defaultDelay: defaultDelay,
+		globalQPS:    globalQPS,
+		delays:       make(map[string]time.Duration),
+		lastFetch:    make(map[string]time.Time),
+	}
+	for i := range s.queues {
+		s.queues[i] = make(chan FetchRequest, 16)
+	}
+	return s
+}
+
+// registeredDomain strips a leading "www." so that www.example.com and
+// example.com share the same queue and the same politeness budget.
+func registeredDomain(domain string) string {
+	if len(domain) > 4 && domain[:4] == "www." {
+		return domain[4:]
+	}
+	return domain
+}
+
+// hostQueue returns the queue index a domain is pinned to. FNV-1a keeps
+// this stable across the lifetime of the process without needing a
+// separate hash import.
+func (s *Scheduler) hostQueue(domain string) int {
+	const offset32 = 2166136261
+	const prime32 = 16777619
+	h := uint32(offset32)
+	for i := 0; i < len(domain); i++ {
+		h ^= uint32(domain[i])
+		h *= prime32
+	}
+	return int(h % uint32(len(s.queues)))
+}
+
+// SetCrawlDelay records a per-host delay, e.g. parsed from a cached
+// robots.txt's Crawl-delay directive, so subsequent refetches honor it
+// instead of the default.
+func (s *Scheduler) SetCrawlDelay(domain string, delay time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.delays[registeredDomain(domain)] = delay
+}
+
+func (s *Scheduler) delayFor(domain string) time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if d, ok := s.delays[registeredDomain(domain)]; ok {
+		return d
+	}
+	return s.defaultDelay
+}
+
+// lastFetchFor and recordFetch track the most recent fetch time per host
+// rather than per queue: -max-per-host bounds the number of queues, so
+// several unrelated hosts can hash onto the same queue and must not be
+// throttled against each other's timestamps.
+func (s *Scheduler) lastFetchFor(domain string) time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lastFetch[registeredDomain(domain)]
+}
+
+func (s *Scheduler) recordFetch(domain string, t time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastFetch[registeredDomain(domain)] = t
+}
+
+// Submit enqueues a request onto its host's queue. It blocks if that
+// queue's buffer is full, which provides natural backpressure per host.
+func (s *Scheduler) Submit(fr FetchRequest) {
+	s.queues[s.hostQueue(registeredDomain(fr.Domain))] <- fr
+}
+
+// Close closes every per-host queue once no more requests will be
+// submitted, letting the drain goroutines exit.
+func (s *Scheduler) Close() {
+	for _, q := range s.queues {
+		close(q)
+	}
+}
+
+// Run drains every per-host queue into fetchPipeline, tracking the last
+// release per host so a host is waited on for at least its delay between
+// requests. A process-wide bucket additionally caps the overall rate at
+// globalQPS when set. Run blocks until every queue is drained and closed.
+func (s *Scheduler) Run(fetchPipeline chan FetchRequest) {
+	var globalTick <-chan time.Time
+	if s.globalQPS > 0 {
+		globalTick = time.Tick(time.Second / time.Duration(s.globalQPS))
+	}
+
+	var wg sync.WaitGroup
+	for i, q := range s.queues {
+		wg.Add(1)
+		go func(idx int, queue chan FetchRequest) {
+			defer wg.Done()
+			for fr := range queue {
+				if wait := s.delayFor(fr.Domain) - time.Since(s.lastFetchFor(fr.Domain)); wait > 0 {
+					time.Sleep(wait)
+				}
+				if globalTick != nil {
+					<-globalTick
+				}
+				log.Debug("SCHED: queue %d releasing %s after waiting for host delay", idx, fr.Domain)
+				s.recordFetch(fr.Domain, time.Now())
+				fetchPipeline <- fr
+			}
+		}(i, q)
+	}
+	wg.Wait()
+}
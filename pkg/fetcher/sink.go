@@ -0,0 +1,351 @@
+package fetcher
+
+import (
+	"bufio"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	_ "github.com/mattn/go-sqlite3"
+	"io"
+	"os"
+	"time"
+)
+
+// Sink is the write side of the pipeline: anything that can durably record a
+// RobotResponse. saveRobots used to hard-code a SQLite table; pulling this
+// out lets -sink pick between SQLite, a SQL database via database/sql, plain
+// JSON lines, or a WARC-style archive without touching the fetcher.
+type Sink interface {
+	// Write persists a single response. Implementations may buffer
+	// internally, but Flush/Close must make buffered writes durable.
+	Write(resp RobotResponse) error
+	// Flush forces any buffered writes out. Called on the save ticker.
+	Flush() error
+	// Close flushes and releases any underlying resources (files, DB
+	// handles). Safe to call once, at shutdown.
+	Close() error
+}
+
+// Source is implemented by sinks that can enumerate the domains they
+// already hold, along with the conditional-request headers from the last
+// fetch of each. -refresh uses this to read its worklist from the sink
+// instead of stdin.
+type Source interface {
+	ReadDomains() ([]CachedEntry, error)
+}
+
+// NewSink constructs the Sink named by -sink. path is sink-specific: a
+// filesystem path for sqlite/json/warc, or a database/sql DSN for sql.
+func NewSink(name, driver, path string) (Sink, error) {
+	switch name {
+	case "sqlite":
+		return newSQLSink("sqlite3", path)
+	case "sql":
+		return newSQLSink(driver, path)
+	case "json":
+		return newJSONSink(path)
+	case "warc":
+		return newWARCSink(path)
+	default:
+		return nil, fmt.Errorf("unknown sink %q", name)
+	}
+}
+
+// sqlSink writes through database/sql, batching inserts into a transaction
+// that is committed once per tick. This replaces the SQLite-only logic that
+// used to live in saveRobots: the transaction reset is the sink's concern,
+// not the fetcher's.
+type sqlSink struct {
+	db        *sql.DB
+	tx        *sql.Tx
+	insertSql *sql.Stmt
+}
+
+func newSQLSink(driver, dsn string) (*sqlSink, error) {
+	db, err := sql.Open(driver, dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	// CREATE TABLE IF NOT EXISTS rather than probing sqlite_master first:
+	// sqlite_master is SQLite-specific and doesn't exist on Postgres/MySQL,
+	// so this has to stay driver-agnostic to support -sink=sql.
+	log.Debug("Ensuring robots table exists...")
+	createSql := `CREATE TABLE IF NOT EXISTS robots(
+		id INTEGER NOT NULL PRIMARY KEY,
+		domain TEXT,
+		url TEXT,
+		hasRobots INT,
+		fetchTime TIMESTAMP,
+		body TEXT,
+		redirects TEXT,
+		scheme TEXT,
+		tlsError TEXT,
+		etag TEXT,
+		lastModified TEXT,
+		contentType TEXT
+		)`
+	if _, err := db.Exec(createSql); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	// Companion tables for the normalized view of a parsed robots.txt,
+	// linked back to robots.id.
+	for _, createSql := range []string{
+		`CREATE TABLE IF NOT EXISTS robots_useragents(
+			id INTEGER NOT NULL PRIMARY KEY,
+			robots_id INTEGER,
+			userAgent TEXT,
+			crawlDelay REAL
+			)`,
+		`CREATE TABLE IF NOT EXISTS robots_rules(
+			id INTEGER NOT NULL PRIMARY KEY,
+			robots_id INTEGER,
+			userAgent TEXT,
+			allow INT,
+			path TEXT
+			)`,
+		`CREATE TABLE IF NOT EXISTS robots_sitemaps(
+			id INTEGER NOT NULL PRIMARY KEY,
+			robots_id INTEGER,
+			url TEXT
+			)`,
+	} {
+		if _, err := db.Exec(createSql); err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
+
+	s := &sqlSink{db: db}
+	if err := s.beginTx(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *sqlSink) beginTx() error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	insertSql, err := tx.Prepare(`insert into
+		robots(domain, url, hasRobots, fetchTime, body, redirects, scheme, tlsError, etag, lastModified, contentType)
+		values(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return err
+	}
+	s.tx = tx
+	s.insertSql = insertSql
+	return nil
+}
+
+func (s *sqlSink) Write(resp RobotResponse) error {
+	result, err := s.insertSql.Exec(
+		resp.Domain,
+		resp.Url,
+		resp.HasRobots,
+		resp.FetchTime,
+		string(resp.Body),
+		resp.Redirects,
+		resp.Scheme,
+		resp.TLSError,
+		resp.ETag,
+		resp.LastModified,
+		resp.ContentType,
+	)
+	if err != nil {
+		return err
+	}
+	if !resp.HasRobots || len(resp.Body) == 0 {
+		return nil
+	}
+	robotsID, err := result.LastInsertId()
+	if err != nil {
+		// Not every driver supports LastInsertId (notably Postgres); the
+		// raw body is already saved, so just skip the side tables rather
+		// than failing the whole crawl.
+		log.Warning(fmt.Sprintf("SINK: couldn't get insert id for %s, skipping parsed tables: %s", resp.Domain, err))
+		return nil
+	}
+	return s.writeParsed(robotsID, resp.Body)
+}
+
+// writeParsed runs the robots.txt parser over body and persists the result
+// into robots_useragents/robots_rules/robots_sitemaps, linked by robots_id.
+// This happens inline with the main insert, in the same transaction, so it
+// shares the id it needs for the foreign key without a second round trip.
+func (s *sqlSink) writeParsed(robotsID int64, body []byte) error {
+	parsed := ParseRobots(body)
+
+	for _, ua := range parsed.UserAgents {
+		delay, hasDelay := parsed.CrawlDelay(ua)
+		delaySeconds := -1.0
+		if hasDelay {
+			delaySeconds = delay.Seconds()
+		}
+		if _, err := s.tx.Exec(
+			`insert into robots_useragents(robots_id, userAgent, crawlDelay) values(?, ?, ?)`,
+			robotsID, ua, delaySeconds,
+		); err != nil {
+			return err
+		}
+		rules, _ := parsed.Rules(ua)
+		for _, rule := range rules {
+			if _, err := s.tx.Exec(
+				`insert into robots_rules(robots_id, userAgent, allow, path) values(?, ?, ?, ?)`,
+				robotsID, ua, rule.Allow, rule.Path,
+			); err != nil {
+				return err
+			}
+		}
+	}
+	for _, sitemap := range parsed.Sitemaps {
+		if _, err := s.tx.Exec(
+			`insert into robots_sitemaps(robots_id, url) values(?, ?)`,
+			robotsID, sitemap,
+		); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Flush commits the current transaction and opens the next one, mirroring
+// the per-tick reset that used to be inlined in saveRobots.
+func (s *sqlSink) Flush() error {
+	s.insertSql.Close()
+	if err := s.tx.Commit(); err != nil {
+		return err
+	}
+	return s.beginTx()
+}
+
+// ReadDomains returns the most recent row for each distinct domain,
+// carrying forward its ETag/Last-Modified so -refresh can make a
+// conditional request rather than a full refetch.
+func (s *sqlSink) ReadDomains() ([]CachedEntry, error) {
+	rows, err := s.db.Query(`select domain, url, hasRobots, body, etag, lastModified
+		from robots
+		where id in (select max(id) from robots group by domain)`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []CachedEntry
+	for rows.Next() {
+		var e CachedEntry
+		var url, body string
+		if err := rows.Scan(&e.Domain, &url, &e.HasRobots, &body, &e.ETag, &e.LastModified); err != nil {
+			return nil, err
+		}
+		e.Body = []byte(body)
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+func (s *sqlSink) Close() error {
+	s.insertSql.Close()
+	if err := s.tx.Commit(); err != nil {
+		s.db.Close()
+		return err
+	}
+	return s.db.Close()
+}
+
+// jsonSink writes one JSON-encoded RobotResponse per line. Unlike
+// RobotsTask.Print, each line holds the JSON object alone, without the
+// leading domain and HasRobots columns.
+type jsonSink struct {
+	f   *os.File
+	w   *bufio.Writer
+	enc *json.Encoder
+}
+
+func newJSONSink(path string) (*jsonSink, error) {
+	var f *os.File
+	var err error
+	if path == "" || path == "-" {
+		f = os.Stdout
+	} else {
+		f, err = os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return nil, err
+		}
+	}
+	w := bufio.NewWriter(f)
+	return &jsonSink{f: f, w: w, enc: json.NewEncoder(w)}, nil
+}
+
+func (s *jsonSink) Write(resp RobotResponse) error {
+	return s.enc.Encode(resp)
+}
+
+func (s *jsonSink) Flush() error {
+	return s.w.Flush()
+}
+
+func (s *jsonSink) Close() error {
+	if err := s.w.Flush(); err != nil {
+		return err
+	}
+	if s.f != os.Stdout {
+		return s.f.Close()
+	}
+	return nil
+}
+
+// warcSink stores the raw fetch alongside request metadata so a crawl can be
+// reproduced later. This is a minimal WARC-like container (not a validating
+// WARC/1.0 writer): each record is a block of metadata header lines, a
+// blank line, the raw body, and a closing blank line, which is enough to
+// round-trip what Fetcher.Fetch saw without losing the original bytes.
+type warcSink struct {
+	f *os.File
+	w *bufio.Writer
+}
+
+func newWARCSink(path string) (*warcSink, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &warcSink{f: f, w: bufio.NewWriter(f)}, nil
+}
+
+func (s *warcSink) Write(resp RobotResponse) error {
+	header := fmt.Sprintf(
+		"WARC-Type: response\r\nWARC-Target-URI: %s\r\nWARC-Date: %s\r\nDomain: %s\r\nHasRobots: %v\r\nRedirects: %d\r\nScheme: %s\r\nTLS-Error: %s\r\nContent-Length: %d\r\n\r\n",
+		resp.Url,
+		resp.FetchTime.UTC().Format(time.RFC3339),
+		resp.Domain,
+		resp.HasRobots,
+		resp.Redirects,
+		resp.Scheme,
+		resp.TLSError,
+		len(resp.Body),
+	)
+	if _, err := io.WriteString(s.w, header); err != nil {
+		return err
+	}
+	if _, err := s.w.Write(resp.Body); err != nil {
+		return err
+	}
+	_, err := io.WriteString(s.w, "\r\n\r\n")
+	return err
+}
+
+func (s *warcSink) Flush() error {
+	return s.w.Flush()
+}
+
+func (s *warcSink) Close() error {
+	if err := s.w.Flush(); err != nil {
+		return err
+	}
+	return s.f.Close()
+}
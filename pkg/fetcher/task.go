@@ -0,0 +1,99 @@
+package fetcher
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// Task is one unit of work the RunTasks pool executes. A robots.txt fetch
+// is the task this package ships, but the interface carries no robots.txt
+// specifics so callers can plug in other per-domain probes -- a sitemap
+// fetch, security.txt, humans.txt -- through the same pool.
+type Task interface {
+	Process(ctx context.Context)
+	Print()
+}
+
+// Factory builds a Task from one line of input, typically a domain.
+type Factory interface {
+	Make(line string) Task
+}
+
+// RobotsTask adapts Fetcher.Fetch into the Task interface.
+type RobotsTask struct {
+	fetcher  *Fetcher
+	domain   string
+	response *RobotResponse
+}
+
+func (t *RobotsTask) Process(ctx context.Context) {
+	resp, err := t.fetcher.Fetch(ctx, t.domain)
+	if err != nil {
+		log.Warning(fmt.Sprintf("%s", err))
+		resp = &RobotResponse{Domain: t.domain}
+	}
+	t.response = resp
+}
+
+func (t *RobotsTask) Print() {
+	payload, _ := json.Marshal(t.response)
+	fmt.Printf("%s\t%v\t%s\n", t.response.Domain, t.response.HasRobots, payload)
+}
+
+// RobotsFactory makes RobotsTasks bound to a shared Fetcher.
+type RobotsFactory struct {
+	Fetcher *Fetcher
+}
+
+func (rf *RobotsFactory) Make(line string) Task {
+	return &RobotsTask{fetcher: rf.Fetcher, domain: line}
+}
+
+// RunTasks reads one line per Task from r, runs them across workers
+// goroutines via factory, and calls Print on each as it completes. This is
+// the task/factory worker pool gogorobotcli's run() used, generalized so
+// it isn't tied to RobotsTask.
+func RunTasks(ctx context.Context, r io.Reader, factory Factory, workers int) {
+	in := make(chan Task)
+	var readGroup sync.WaitGroup
+	readGroup.Add(1)
+	go func() {
+		defer readGroup.Done()
+		defer close(in)
+		s := bufio.NewScanner(r)
+		for s.Scan() {
+			select {
+			case in <- factory.Make(s.Text()):
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	out := make(chan Task)
+	var workerGroup sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		workerGroup.Add(1)
+		go func() {
+			defer workerGroup.Done()
+			for t := range in {
+				t.Process(ctx)
+				out <- t
+			}
+		}()
+	}
+
+	go func() {
+		workerGroup.Wait()
+		close(out)
+	}()
+
+	for t := range out {
+		t.Print()
+	}
+	readGroup.Wait()
+}